@@ -0,0 +1,67 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	clfTimestampFormat = "02/Jan/2006:15:04:05 -0700"
+
+	CLFRemoteAddr = "remote_addr"
+	CLFUser       = "user"
+	CLFMethod     = "method"
+	CLFPath       = "path"
+	CLFProto      = "proto"
+	CLFStatus     = "status"
+	CLFBytes      = "bytes"
+)
+
+type CLFFieldMap map[string]string
+
+func (m CLFFieldMap) resolve(key string) string {
+	if k, ok := m[key]; ok {
+		return k
+	}
+	return key
+}
+
+func (f *TextFormatter) formatCLF(entry *logrus.Entry) (string, bool) {
+	values := make(map[string]string)
+	for _, key := range []string{CLFRemoteAddr, CLFUser, CLFMethod, CLFPath, CLFProto, CLFStatus, CLFBytes} {
+		if v, ok := entry.Data[f.CLFFieldMap.resolve(key)]; ok {
+			values[key] = fmt.Sprint(v)
+		}
+	}
+
+	for _, key := range []string{CLFRemoteAddr, CLFMethod, CLFPath, CLFStatus, CLFBytes} {
+		if values[key] == "" {
+			return "", false
+		}
+	}
+
+	user := values[CLFUser]
+	if user == "" {
+		user = "-"
+	}
+	request := values[CLFMethod] + " " + values[CLFPath]
+	if proto := values[CLFProto]; proto != "" {
+		request += " " + proto
+	}
+	size := values[CLFBytes]
+	if size == "0" {
+		size = "-"
+	}
+
+	return strings.Join([]string{
+		values[CLFRemoteAddr],
+		"-",
+		user,
+		"[" + entry.Time.Format(clfTimestampFormat) + "]",
+		fmt.Sprintf("%q", request),
+		values[CLFStatus],
+		size,
+	}, " "), true
+}
@@ -0,0 +1,214 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type ComplexValueStyle int
+
+const (
+	ComplexValueGo ComplexValueStyle = iota
+	ComplexValueJSON
+	ComplexValueYAMLFlow
+)
+
+func isComplexValue(value interface{}) bool {
+	switch value.(type) {
+	case error, fmt.Stringer:
+		return false
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	}
+	return false
+}
+
+func (f *TextFormatter) formatComplexValue(value interface{}) (string, bool) {
+	if f.ComplexValueStyle == ComplexValueGo || !isComplexValue(value) {
+		return "", false
+	}
+
+	switch f.ComplexValueStyle {
+	case ComplexValueJSON:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", false
+		}
+		return string(encoded), true
+	case ComplexValueYAMLFlow:
+		var b strings.Builder
+		writeYAMLFlow(&b, reflect.ValueOf(value))
+		return b.String(), true
+	}
+	return "", false
+}
+
+func writeYAMLFlow(b *strings.Builder, v reflect.Value) {
+	for {
+		if text, ok := stringerText(v); ok {
+			b.WriteString(yamlScalar(text))
+			return
+		}
+		if v.Kind() != reflect.Interface && v.Kind() != reflect.Ptr {
+			break
+		}
+		if v.IsNil() {
+			b.WriteString("null")
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+		byName := make(map[string]reflect.Value, len(keys))
+		for i, k := range keys {
+			names[i] = fmt.Sprint(k.Interface())
+			byName[names[i]] = v.MapIndex(k)
+		}
+		sort.Strings(names)
+
+		b.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(yamlScalar(name))
+			b.WriteString(": ")
+			writeYAMLFlow(b, byName[name])
+		}
+		b.WriteByte('}')
+	case reflect.Struct:
+		t := v.Type()
+		b.WriteByte('{')
+		written := 0
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			if written > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(t.Field(i).Name)
+			b.WriteString(": ")
+			writeYAMLFlow(b, v.Field(i))
+			written++
+		}
+		b.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeYAMLFlow(b, v.Index(i))
+		}
+		b.WriteByte(']')
+	case reflect.String:
+		b.WriteString(yamlScalar(v.String()))
+	default:
+		if v.CanInterface() {
+			b.WriteString(fmt.Sprint(v.Interface()))
+		} else {
+			b.WriteString(v.String())
+		}
+	}
+}
+
+// stringerText renders nested errors and fmt.Stringers, such as time.Time,
+// through their own methods, since their fields are usually unexported.
+func stringerText(v reflect.Value) (string, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return "", false
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return "", false
+	}
+	switch value := v.Interface().(type) {
+	case error:
+		return value.Error(), true
+	case fmt.Stringer:
+		return value.String(), true
+	}
+	return "", false
+}
+
+func yamlScalar(text string) string {
+	if text == "" || strings.TrimSpace(text) != text || strings.ContainsAny(text, ",:{}[]#&*!|>'\"%@`\n") {
+		return fmt.Sprintf("%q", text)
+	}
+	switch strings.ToLower(text) {
+	case "null", "true", "false", "~":
+		return fmt.Sprintf("%q", text)
+	}
+	return text
+}
+
+func isTreeValue(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.Map || v.Kind() == reflect.Struct
+}
+
+func writeTree(b *strings.Builder, v reflect.Value, depth int) {
+	for {
+		if text, ok := stringerText(v); ok {
+			b.WriteString(" " + yamlScalar(text) + "\n")
+			return
+		}
+		if v.Kind() != reflect.Interface && v.Kind() != reflect.Ptr {
+			break
+		}
+		if v.IsNil() {
+			b.WriteString(" null\n")
+			return
+		}
+		v = v.Elem()
+	}
+
+	indent := strings.Repeat("  ", depth)
+	switch v.Kind() {
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+		byName := make(map[string]reflect.Value, len(keys))
+		for i, k := range keys {
+			names[i] = fmt.Sprint(k.Interface())
+			byName[names[i]] = v.MapIndex(k)
+		}
+		sort.Strings(names)
+
+		b.WriteByte('\n')
+		for _, name := range names {
+			b.WriteString(indent + yamlScalar(name) + ":")
+			writeTree(b, byName[name], depth+1)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		b.WriteByte('\n')
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			b.WriteString(indent + t.Field(i).Name + ":")
+			writeTree(b, v.Field(i), depth+1)
+		}
+	default:
+		b.WriteByte(' ')
+		writeYAMLFlow(b, v)
+		b.WriteByte('\n')
+	}
+}
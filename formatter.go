@@ -4,12 +4,19 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 	"unicode/utf8"
 
 	isatty "github.com/mattn/go-isatty"
@@ -17,38 +24,402 @@ import (
 )
 
 const (
-	defaultTimestampFormat = "2006-01-02 15:04:05 MST"
+	defaultTimestampFormat    = "2006-01-02 15:04:05 MST"
+	defaultContinuationPrefix = "  "
+	compactTimestampFormat    = "20060102T150405Z0700"
+	contextIndicator          = "(ctx)"
+	emptyEntryMarker          = "(empty)"
 
-	faint  = 2
-	red    = 31
-	yellow = 33
-	blue   = 36
-	gray   = 37
+	levelSeparatorWidth = 60
+	severityGutterWidth = 2
+	defaultMaxURLLength = 40
+	sparklineTicks      = "▁▂▃▄▅▆▇█"
+
+	logrusPackage       = "github.com/sirupsen/logrus"
+	maximumCallerDepth  = 64
+	maxDereferenceDepth = 8
+
+	colorReset       = "\x1b[0m"
+	truncationMarker = "…"
+
+	faint    = 2
+	red      = 31
+	green    = 32
+	yellow   = 33
+	darkBlue = 34
+	magenta  = 35
+	blue     = 36
+	gray     = 37
+)
+
+var (
+	pid                = os.Getpid()
+	formatterPackage   = reflect.TypeOf((*TextFormatter)(nil)).Elem().PkgPath()
+	placeholderPattern = regexp.MustCompile(`\{[^{}]+\}`)
+	colorPattern       = regexp.MustCompile("\x1b\\[[0-9;]*m")
+)
+
+type Epoch int
+
+const (
+	EpochOff Epoch = iota
+	EpochSeconds
+	EpochMillis
+	EpochNanos
+)
+
+type ColorExtent int
+
+const (
+	ColorExtentLevelOnly ColorExtent = iota
+	ColorExtentThroughSeparator
+	ColorExtentThroughMessage
+)
+
+type MessageCase int
+
+const (
+	MessageCaseAsIs MessageCase = iota
+	MessageCaseLower
+	MessageCaseUpper
+	// MessageCaseSentence upper-cases the first letter and leaves the rest
+	// of the message untouched.
+	MessageCaseSentence
+)
+
+// EmptyEntryBehavior controls entries with neither a message nor fields.
+type EmptyEntryBehavior int
+
+const (
+	EmptyEntryRender EmptyEntryBehavior = iota
+	// EmptyEntrySkip returns no bytes, so nothing is written.
+	EmptyEntrySkip
+	// EmptyEntryMarker renders a faint "(empty)" in place of the message.
+	EmptyEntryMarker
+)
+
+// FieldColorInheritance selects where field keys take their color from.
+type FieldColorInheritance int
+
+const (
+	FieldColorFromLevel FieldColorInheritance = iota
+	FieldColorNone
+	// FieldColorFromMessage matches the message: the level color under
+	// ColorExtentThroughMessage, uncolored otherwise.
+	FieldColorFromMessage
 )
 
+type FieldStyle struct {
+	KeyColor   int
+	ValueColor int
+}
+
 type TextFormatter struct {
 	TimestampFormat  string
 	DisableTimestamp bool
+	TimestampEpoch   Epoch
+	TimestampCompact bool
+
+	TimestampPlaceholder string
+
+	// TimestampField names a field holding the event time to render instead
+	// of entry.Time, as a time.Time or a string in RFC 3339 or the timestamp
+	// format. The field is never printed; unparseable values fall back to
+	// entry.Time.
+	TimestampField string
+
+	// ShowTID renders the OS thread ID on Linux and falls back to the
+	// goroutine ID on other platforms.
+	ShowPID bool
+	ShowTID bool
+
+	Version string
+
+	// ShowLogRate appends a faint "(N/s)" counting the entries logged in the
+	// one-second window ending at the current entry's time. It keeps one
+	// timestamp per entry in that window behind a mutex, so memory grows
+	// with the logging rate during a storm.
+	ShowLogRate bool
+
+	// VerboseFirstLine prefixes the first entry the formatter renders with
+	// its full RFC 3339 time and the hostname, as an anchor for the compact
+	// lines that follow. Version is already appended to every line.
+	VerboseFirstLine bool
+
+	SecondaryTimezone        *time.Location
+	SecondaryTimestampFormat string
 
 	ForceColors   bool
 	DisableColors bool
+	LevelColors   map[logrus.Level]int
+
+	// UniformLevelColor colors every level with one color instead of the
+	// per-severity defaults. Entries in LevelColors still take precedence.
+	UniformLevelColor int
 
 	ForceQuote   bool
 	DisableQuote bool
+	QuoteChar    rune
+
+	// CompactValueWhitespace collapses every whitespace run in string values,
+	// newlines included, to a single space and trims both ends.
+	CompactValueWhitespace bool
+
+	// DereferencePointers renders the value a pointer refers to, following
+	// up to eight levels of pointers, instead of its address. Pointers that
+	// implement error or fmt.Stringer are left alone, and nil pointers
+	// render as NilPointerText ("<nil>" when empty).
+	DereferencePointers bool
+	NilPointerText      string
+
+	PercentEncodeValues bool
+
+	// FloatPrecision prints floats with that many decimals. Zero leaves them
+	// as fmt prints them, so rounding to whole numbers is not supported.
+	FloatPrecision int
+	NaNText        string
+	InfText        string
+
+	// RedactSecretValues replaces string values that look like secrets,
+	// whatever their key, in the rendered line and in the tee output.
+	// SecretDetectors overrides the built-in patterns (JWTs, AWS, GitHub
+	// and Slack tokens, PEM private keys). Setting SecretEntropyBits (4.0 is
+	// a reasonable start) also redacts values of 20 or more base64 or hex
+	// characters whose Shannon entropy reaches it. Hashes and random IDs
+	// can trip that check; URLs and paths never do, as their dots and
+	// colons fall outside the charset.
+	RedactSecretValues bool
+	RedactReplacement  string
+	SecretDetectors    []*regexp.Regexp
+	SecretEntropyBits  float64
+
+	PadShortValues int
+
+	// MaxFieldValueLength caps rendered values, FieldMaxValueLengths per
+	// field. Values rendered as JSON or YAML flow are left whole so they
+	// stay parseable.
+	MaxFieldValueLength  int
+	FieldMaxValueLengths map[string]int
+	MiddleTruncate       bool
+
+	ComplexValueStyle ComplexValueStyle
+	TreeFields        []string
+
+	// ContinuationPrefix starts every continuation line: the lines of a
+	// multi-line message after the first and the lines of TreeFields.
+	// Tree lines are indented by two spaces when it is empty.
+	ContinuationPrefix string
+
+	// BoolAsInt renders booleans as 1 and 0. CheckmarkBools takes
+	// precedence on colored output.
+	BoolAsInt      bool
+	CheckmarkBools bool
+	TrueColor      int
+	FalseColor     int
+
+	TypeColors  map[reflect.Kind]int
+	FieldStyles map[string]FieldStyle
+
+	ValueColorMap map[string]int
+
+	ColorIPFields   []string
+	LoopbackIPColor int
+	PrivateIPColor  int
+	PublicIPColor   int
+
+	ColumnDivider string
+	ColumnSpacing int
+	ColorExtent   ColorExtent
+
+	FieldColorInherits FieldColorInheritance
 
 	TruncateLevelText bool
 	PadLevelText      bool
+	LevelMinWidth     int
+	LevelMaxWidth     int
+
+	SeverityGutter        bool
+	SeverityGutterSymbols map[logrus.Level]string
+
+	UnderlineLevels map[logrus.Level]bool
+	// BlinkLevels is best-effort: many terminals ignore the blink attribute.
+	BlinkLevels map[logrus.Level]bool
+
+	SparklineFields []string
+
+	ShortenURLFields []string
+	MaxURLLength     int
+
+	HumanizeByteFields []string
+	HumanizeByteSuffix bool
+	SIByteUnits        bool
+
+	MessageFieldSummary string
+
+	// MessageIcons prepends an icon to messages containing one of its keys.
+	// Prefix matches win over substring matches; ties go to the key that
+	// sorts first.
+	MessageIcons map[string]string
+
+	MessageLevelColorMap map[string]int
+
+	// MessageCase is applied to the message before interpolation, so
+	// MessageLevelColorMap and MessageIcons match the re-cased text.
+	MessageCase MessageCase
+
+	// LevelIndent indents the message of matching levels by that many
+	// spaces. The indent counts toward the message column, so fields stay
+	// aligned unless the message overflows it.
+	LevelIndent map[logrus.Level]int
+
+	InterpolateMessage       bool
+	RemoveInterpolatedFields bool
+
+	NoFieldsIndicator string
+
+	EmptyEntryBehavior EmptyEntryBehavior
+
+	// ShowContextIndicator appends a faint "(ctx)" to entries logged with a
+	// context.Context attached.
+	ShowContextIndicator bool
 
-	DisableSorting bool
+	// MarkChangedValues makes the formatter stateful: it keeps the rendered
+	// fields of the previous entry (only that entry, so memory stays bounded
+	// by one line) and prefixes values that differ from it with a "*".
+	MarkChangedValues bool
+
+	OmitZeroValueFields bool
+	ZeroValueFunc       func(key string, value interface{}) bool
+
+	// KeyAbbreviations and MaxKeyLength only change how keys are shown;
+	// per-field options such as InlineFields, FieldStyles and ColorIPFields
+	// match the original field name.
+	KeyAbbreviations map[string]string
+	MaxKeyLength     int
+
+	// FieldKeyPrefix is prepended to every rendered field key. It is added
+	// after abbreviation and clamping, and since every key carries it the
+	// sort order is unchanged; SortingFunc still sees the unprefixed keys.
+	FieldKeyPrefix string
+
+	InlineFields []string
+
+	KeyValueSeparator string
+	SpaceAroundEquals bool
+
+	FieldBlockDelimiters [2]string
+
+	CompactFields  bool
+	FieldSeparator string
+
+	// MessageFieldSeparator replaces the space before the first trailing
+	// field. It is left out when the message is empty.
+	MessageFieldSeparator string
+
+	DisableSorting    bool
+	SortByValueLength bool
 
 	SortingFunc func([]string)
 
 	CallerPrettyfier func(*runtime.Frame) (function string, file string)
 
+	// PostProcess receives the fully rendered entry and its return value
+	// becomes the result of Format. It must keep the trailing newline if
+	// the output is expected to stay line oriented.
+	PostProcess func(formatted []byte) []byte
+
+	MaxLineBytes int
+
+	TeeWriter       io.Writer
+	TeeFormat       TeeFormat
+	ReportTeeErrors bool
+
+	// OverrideFieldKey names a field (for example "_fmt") whose
+	// FormatOverride, or comma-separated flags such as "raw,nocolor",
+	// changes how that one entry renders. The field itself is never
+	// printed. Empty disables overrides.
+	OverrideFieldKey string
+
+	DisableGenericParamStripping bool
+
+	CallerFuncOnly bool
+
+	AbbreviateCallerPath bool
+	CallerPathSegments   int
+	CallerWidth          int
+
+	// NormalizeCallerSeparators rewrites backslashes in the caller file as
+	// forward slashes on every platform, so Windows paths render the same
+	// way as Go source paths.
+	NormalizeCallerSeparators bool
+
+	CallerChainDepth int
+	CallerChainArrow string
+
+	// ElideRepeatedCaller makes the formatter stateful: it remembers the
+	// caller of the previous entry and renders RepeatedCallerMarker (an
+	// arrow by default) instead when the next entry has the same one.
+	ElideRepeatedCaller  bool
+	RepeatedCallerMarker string
+
+	PromoteErrorField bool
+	ErrorFieldKey     string
+
+	// SeparateLevelChanges makes the formatter stateful: it remembers the
+	// level of the previous entry and writes a separator line before any
+	// entry whose level differs. With SeparateOnEscalationOnly the line is
+	// only written when the level escalates to warn or a more severe level.
+	SeparateLevelChanges     bool
+	SeparateOnEscalationOnly bool
+
+	// OutputSyslog prefixes every line with a syslog <PRI>. SyslogFacility
+	// is the facility code, 0 (kern) through 23 (local7); values outside
+	// that range are clamped to it.
+	OutputSyslog   bool
+	SyslogFacility int
+
+	RenderColorSwatches bool
+
+	// RestrictedPalette maps every color to its nearest entry by RGB
+	// distance. Only the 16 basic SGR foreground codes (30-37 and 90-97)
+	// are understood; other entries are ignored, as are colors outside that
+	// range, which pass through unchanged.
+	RestrictedPalette []int
+
+	EmitColorLegend bool
+
+	// ColorblindSafe replaces red with magenta and green with blue wherever
+	// they are used, so errors, warnings (yellow) and true/false marks stay
+	// distinguishable without relying on red/green contrast.
+	ColorblindSafe bool
+
+	OutputCLF   bool
+	CLFFieldMap CLFFieldMap
+
 	terminalInitOnce sync.Once
+	legendOnce       sync.Once
+	firstLineOnce    sync.Once
 
 	isTerminal         bool
+	isTrueColor        bool
 	levelTextMaxLength int
+
+	ringBuffer *RingBuffer
+
+	teeMu sync.Mutex
+
+	valuesMu   sync.Mutex
+	lastValues map[string]string
+
+	callerMu   sync.Mutex
+	lastCaller string
+
+	levelMu      sync.Mutex
+	lastLevel    logrus.Level
+	hasLastLevel bool
+
+	rateMu    sync.Mutex
+	rateTimes []time.Time
 }
 
 func isTerminal(w io.Writer) bool {
@@ -63,6 +434,10 @@ func (f *TextFormatter) init(entry *logrus.Entry) {
 	if entry.Logger != nil {
 		f.isTerminal = isTerminal(entry.Logger.Out)
 	}
+	switch os.Getenv("COLORTERM") {
+	case "truecolor", "24bit":
+		f.isTrueColor = true
+	}
 	for _, level := range logrus.AllLevels {
 		levelTextLength := utf8.RuneCount([]byte(level.String()))
 		if levelTextLength > f.levelTextMaxLength {
@@ -79,146 +454,1427 @@ func (f *TextFormatter) isColored() bool {
 
 func colorPrint(text string, color int) string {
 	if color > 0 {
-		return fmt.Sprintf("\x1b[%dm%s\x1b[0m", color, text)
+		return fmt.Sprintf("\x1b[%dm%s%s", color, text, colorReset)
 	}
 	return text
 }
 
-func (f *TextFormatter) needsQuoting(text string) bool {
-	if f.ForceQuote {
-		return true
+func abbreviatePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 0; i < len(segments)-1; i++ {
+		parts := strings.Split(segments[i], ".")
+		for j, part := range parts {
+			if r, size := utf8.DecodeRuneInString(part); size > 0 {
+				parts[j] = string(r)
+			}
+		}
+		segments[i] = strings.Join(parts, ".")
 	}
-	if f.DisableQuote {
-		return false
+	return strings.Join(segments, "/")
+}
+
+func stripGenericParams(function string) string {
+	if !strings.Contains(function, "[") {
+		return function
 	}
-	for _, ch := range text {
-		if !((ch >= 'a' && ch <= 'z') ||
-			(ch >= 'A' && ch <= 'Z') ||
-			(ch >= '0' && ch <= '9') ||
-			ch == '-' || ch == '.' || ch == '_' || ch == '/' || ch == '@' || ch == '^' || ch == '+') {
-			return true
+
+	var b strings.Builder
+	depth := 0
+	for _, ch := range function {
+		switch {
+		case ch == '[':
+			depth++
+		case ch == ']' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(ch)
 		}
 	}
-	return false
+	return b.String()
 }
 
-func (f *TextFormatter) appendValue(b *bytes.Buffer, value interface{}) {
-	stringVal, ok := value.(string)
-	if !ok {
-		stringVal = fmt.Sprint(value)
+func bareFunctionName(function string) string {
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		function = function[i+1:]
+	}
+	if i := strings.Index(function, "."); i >= 0 {
+		function = function[i+1:]
 	}
 
-	if !f.needsQuoting(stringVal) {
-		b.WriteString(stringVal)
-	} else {
-		b.WriteString(fmt.Sprintf("%q", stringVal))
+	parts := strings.Split(function, ".")
+	i := len(parts) - 1
+	for i > 0 && isClosureName(parts[i]) {
+		i--
 	}
+	return strings.Join(parts[i:], ".")
 }
 
-func (f *TextFormatter) Format(entry *logrus.Entry) ([]byte, error) {
-	data := make(logrus.Fields)
-	for k, v := range entry.Data {
-		data[k] = v
+func isClosureName(name string) bool {
+	digits := strings.TrimPrefix(name, "func")
+	if digits == "" {
+		return false
 	}
+	_, err := strconv.Atoi(digits)
+	return err == nil
+}
 
-	keys := make([]string, 0, len(data))
-	for k := range data {
-		keys = append(keys, k)
+func lastPathSegments(path string, n int) string {
+	segments := strings.Split(path, "/")
+	if len(segments) <= n {
+		return path
 	}
+	return strings.Join(segments[len(segments)-n:], "/")
+}
 
-	if !f.DisableSorting {
-		if f.SortingFunc == nil {
-			sort.Strings(keys)
-		} else {
-			f.SortingFunc(keys)
-		}
+func truncateHead(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
 	}
+	return "…" + string(runes[len(runes)-width+1:])
+}
 
-	var b *bytes.Buffer
-	if entry.Buffer != nil {
-		b = entry.Buffer
-	} else {
-		b = &bytes.Buffer{}
+func visibleLength(text string) int {
+	return utf8.RuneCountInString(colorPattern.ReplaceAllString(text, ""))
+}
+
+func clampWidth(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
 	}
+	return string(runes[:width-1]) + "…"
+}
 
-	f.terminalInitOnce.Do(func() { f.init(entry) })
+func truncateMiddle(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	head := (width - 1) - (width-1)/2
+	tail := (width - 1) / 2
+	return string(runes[:head]) + "…" + string(runes[len(runes)-tail:])
+}
 
-	timestampFormat := f.TimestampFormat
-	if timestampFormat == "" {
-		timestampFormat = defaultTimestampFormat
+func padRight(text string, width int) string {
+	if length := visibleLength(text); length < width {
+		return text + strings.Repeat(" ", width-length)
 	}
+	return text
+}
 
-	timestamp := entry.Time.Format(timestampFormat)
+func goroutineID() int {
+	var buf [64]byte
+	stack := buf[:runtime.Stack(buf[:], false)]
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i > 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.Atoi(string(stack))
+	return id
+}
 
-	levelColor := -1
-	separator := " :: "
+func (f *TextFormatter) processIDs() string {
+	var ids []string
+	if f.ShowPID {
+		ids = append(ids, strconv.Itoa(pid))
+	}
+	if f.ShowTID {
+		ids = append(ids, strconv.Itoa(threadID()))
+	}
+	return strings.Join(ids, "/")
+}
 
-	if f.isColored() {
-		switch entry.Level {
-		case logrus.DebugLevel, logrus.TraceLevel:
-			levelColor = gray
+func (f *TextFormatter) gutterSymbol(level logrus.Level) string {
+	symbol, ok := f.SeverityGutterSymbols[level]
+	if !ok {
+		switch level {
 		case logrus.WarnLevel:
-			levelColor = yellow
+			symbol = "!"
 		case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
-			levelColor = red
-		default:
-			levelColor = blue
+			symbol = "x"
 		}
+	}
+	if runes := []rune(symbol); len(runes) > severityGutterWidth-1 {
+		symbol = string(runes[:severityGutterWidth-1])
+	}
+	return padRight(symbol, severityGutterWidth)
+}
 
-		timestamp = colorPrint(timestamp, faint)
-		separator = " "
+func truncateLine(line []byte, max int) []byte {
+	end, escaped := 0, false
+	for end < len(line) {
+		_, size := utf8.DecodeRune(line[end:])
+		if line[end] == '\x1b' {
+			if loc := escapePattern.FindIndex(line[end:]); loc != nil && loc[0] == 0 {
+				size, escaped = loc[1], true
+			}
+		}
+		if end+size > max {
+			break
+		}
+		end += size
 	}
 
-	levelText := strings.ToUpper(entry.Level.String())
+	truncated := append([]byte(nil), line[:end]...)
+	if escaped {
+		truncated = append(truncated, colorReset...)
+	}
+	return append(truncated, truncationMarker...)
+}
 
-	if f.TruncateLevelText && !f.PadLevelText {
-		levelText = levelText[0:4]
+func (f *TextFormatter) callerRepeated(caller string) bool {
+	f.callerMu.Lock()
+	defer f.callerMu.Unlock()
+
+	repeated := caller == f.lastCaller
+	f.lastCaller = caller
+	return repeated
+}
+
+func (f *TextFormatter) changedValues(values map[string]string) map[string]bool {
+	f.valuesMu.Lock()
+	defer f.valuesMu.Unlock()
+
+	changed := make(map[string]bool)
+	for k, v := range values {
+		if previous, ok := f.lastValues[k]; ok && previous != v {
+			changed[k] = true
+		}
 	}
-	if f.PadLevelText {
-		formatString := "%-" + strconv.Itoa(f.levelTextMaxLength) + "s"
-		levelText = fmt.Sprintf(formatString, levelText)
+	f.lastValues = values
+	return changed
+}
+
+func firstLineAnchor(t time.Time) string {
+	anchor := t.Format(time.RFC3339)
+	if hostname, err := os.Hostname(); err == nil {
+		anchor += " " + hostname
 	}
+	return anchor
+}
 
-	entry.Message = strings.TrimSuffix(entry.Message, "\n")
+func (f *TextFormatter) logRate(t time.Time) int {
+	f.rateMu.Lock()
+	defer f.rateMu.Unlock()
 
-	caller := ""
-	if entry.HasCaller() {
-		var (
-			funcVal = fmt.Sprintf("%s:%d", entry.Caller.Function, entry.Caller.Line)
-			fileVal string
-		)
+	t = t.Round(0)
+	cutoff := t.Add(-time.Second)
+	kept := f.rateTimes[:0]
+	for _, seen := range f.rateTimes {
+		if seen.After(cutoff) {
+			kept = append(kept, seen)
+		}
+	}
+	f.rateTimes = append(kept, t)
+	return len(f.rateTimes)
+}
+
+func (f *TextFormatter) levelChanged(level logrus.Level) bool {
+	f.levelMu.Lock()
+	defer f.levelMu.Unlock()
+
+	previous, hadPrevious := f.lastLevel, f.hasLastLevel
+	f.lastLevel, f.hasLastLevel = level, true
+
+	if !hadPrevious || previous == level {
+		return false
+	}
+	if f.SeparateOnEscalationOnly {
+		return level < previous && level <= logrus.WarnLevel
+	}
+	return true
+}
+
+func (f *TextFormatter) syslogFacility() int {
+	switch {
+	case f.SyslogFacility < 0:
+		return 0
+	case f.SyslogFacility > 23:
+		return 23
+	}
+	return f.SyslogFacility
+}
+
+func syslogSeverity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel:
+		return 0
+	case logrus.FatalLevel:
+		return 2
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7
+	}
+}
+
+func hexColor(text string) (r, g, b uint8, ok bool) {
+	if len(text) != 7 || text[0] != '#' {
+		return 0, 0, 0, false
+	}
+	rgb, err := strconv.ParseUint(text[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), true
+}
+
+var basicColorRGB = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+func colorRGB(color int) ([3]int, bool) {
+	switch {
+	case color >= 30 && color <= 37:
+		return basicColorRGB[color-30], true
+	case color >= 90 && color <= 97:
+		return basicColorRGB[color-90+8], true
+	}
+	return [3]int{}, false
+}
 
-		if f.CallerPrettyfier != nil {
-			funcVal, fileVal = f.CallerPrettyfier(entry.Caller)
+func (f *TextFormatter) paletteColor(color int) int {
+	if f.ColorblindSafe {
+		switch color {
+		case red:
+			color = magenta
+		case green:
+			color = darkBlue
 		}
+	}
 
-		if fileVal == "" {
-			caller = funcVal
-		} else if funcVal == "" {
-			caller = fileVal
-		} else {
-			caller = fileVal + " " + funcVal
+	rgb, ok := colorRGB(color)
+	if len(f.RestrictedPalette) == 0 || !ok {
+		return color
+	}
+
+	nearest, nearestDistance := color, -1
+	for _, candidate := range f.RestrictedPalette {
+		candidateRGB, ok := colorRGB(candidate)
+		if !ok {
+			continue
+		}
+		distance := 0
+		for i := range rgb {
+			d := rgb[i] - candidateRGB[i]
+			distance += d * d
 		}
+		if nearestDistance < 0 || distance < nearestDistance {
+			nearest, nearestDistance = candidate, distance
+		}
+	}
+	return nearest
+}
 
-		caller = " (" + caller + ")"
+func (f *TextFormatter) colorForLevel(level logrus.Level) int {
+	if color, ok := f.LevelColors[level]; ok {
+		return color
 	}
+	if f.UniformLevelColor > 0 {
+		return f.UniformLevelColor
+	}
+	switch level {
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return gray
+	case logrus.WarnLevel:
+		return yellow
+	case logrus.ErrorLevel, logrus.FatalLevel, logrus.PanicLevel:
+		return red
+	default:
+		return blue
+	}
+}
 
-	switch {
-	case f.DisableTimestamp:
-		colorSection := colorPrint(fmt.Sprintf("%s%s", levelText, caller), levelColor)
-		template := fmt.Sprintf("%%s%s%%-44s ", separator)
-		fmt.Fprintf(b, template, colorSection, entry.Message)
+func (f *TextFormatter) colorLegend() string {
+	legend := []string{"colors:"}
+	for _, level := range []logrus.Level{logrus.DebugLevel, logrus.InfoLevel, logrus.WarnLevel, logrus.ErrorLevel} {
+		legend = append(legend, f.colorPrint(level.String(), f.colorForLevel(level)))
+	}
+	return strings.Join(legend, " ")
+}
+
+func (f *TextFormatter) colorPrint(text string, color int) string {
+	return colorPrint(text, f.paletteColor(color))
+}
+
+func (f *TextFormatter) boolColor(value bool) int {
+	if value {
+		return f.TrueColor
+	}
+	return f.FalseColor
+}
+
+func (f *TextFormatter) isZeroValue(key string, value interface{}) bool {
+	if f.ZeroValueFunc != nil {
+		return f.ZeroValueFunc(key, value)
+	}
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func (f *TextFormatter) isByteField(key string) bool {
+	if f.HumanizeByteSuffix && strings.HasSuffix(key, "_bytes") {
+		return true
+	}
+	return containsKey(f.HumanizeByteFields, key)
+}
+
+func (f *TextFormatter) shortenURL(value interface{}) interface{} {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case *url.URL:
+		text = v.String()
 	default:
-		colorSection := colorPrint(fmt.Sprintf("%s%s", levelText, caller), levelColor)
-		template := fmt.Sprintf("%%s%s%%s%[1]s%%-44s ", separator)
-		fmt.Fprintf(b, template, timestamp, colorSection, entry.Message)
+		return value
+	}
+
+	maxLength := f.MaxURLLength
+	if maxLength <= 0 {
+		maxLength = defaultMaxURLLength
+	}
+	u, err := url.Parse(text)
+	if err != nil || u.Scheme == "" || u.Host == "" || utf8.RuneCountInString(text) <= maxLength {
+		return value
 	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
+
+func containsKey(keys []string, key string) bool {
 	for _, k := range keys {
-		v := data[k]
-		fmt.Fprintf(b, " %s=", colorPrint(k, levelColor))
-		f.appendValue(b, v)
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func sparkline(value interface{}) (string, bool) {
+	v := reflect.ValueOf(value)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() == 0 {
+		return "", false
 	}
 
-	b.WriteByte('\n')
-	return b.Bytes(), nil
+	points := make([]float64, v.Len())
+	for i := range points {
+		point, ok := numericValue(v.Index(i))
+		if !ok || math.IsNaN(point) || math.IsInf(point, 0) {
+			return "", false
+		}
+		points[i] = point
+	}
+
+	low, high := points[0], points[0]
+	for _, point := range points {
+		low, high = math.Min(low, point), math.Max(high, point)
+	}
+
+	// Halving both ends keeps the span finite near ±MaxFloat64.
+	span := high/2 - low/2
+	ticks := []rune(sparklineTicks)
+	line := make([]rune, len(points))
+	for i, point := range points {
+		tick := 0
+		if span > 0 {
+			tick = int((point/2 - low/2) / span * float64(len(ticks)-1))
+		}
+		if tick < 0 {
+			tick = 0
+		} else if tick > len(ticks)-1 {
+			tick = len(ticks) - 1
+		}
+		line[i] = ticks[tick]
+	}
+	return string(line), true
+}
+
+func numericValue(v reflect.Value) (float64, bool) {
+	for v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	}
+	return 0, false
+}
+
+func byteCount(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	}
+	return 0, false
+}
+
+func humanizeBytes(size float64, si bool) string {
+	unit, prefixes, suffix := 1024.0, "KMGTPE", "iB"
+	if si {
+		unit, prefixes, suffix = 1000.0, "kMGTPE", "B"
+	}
+
+	if math.Abs(size) < unit {
+		return fmt.Sprintf("%d B", int64(size))
+	}
+	exponent := 0
+	for size /= unit; math.Abs(size) >= unit && exponent < len(prefixes)-1; size /= unit {
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %c%s", size, prefixes[exponent], suffix)
+}
+
+// interpolate resolves placeholders against fields, which should be the
+// entry's own data so fields omitted from the output still interpolate, and
+// returns the keys it substituted.
+func (f *TextFormatter) interpolate(message string, fields logrus.Fields, overrideKey string) (string, []string) {
+	var interpolated []string
+	message = placeholderPattern.ReplaceAllStringFunc(message, func(token string) string {
+		key := token[1 : len(token)-1]
+		value, ok := fields[key]
+		if !ok || key == overrideKey {
+			return token
+		}
+		interpolated = append(interpolated, key)
+		return f.renderValue(key, value)
+	})
+	return message, interpolated
+}
+
+func (f *TextFormatter) needsQuoting(text string) bool {
+	if f.ForceQuote {
+		return true
+	}
+	if f.DisableQuote {
+		return false
+	}
+	for _, ch := range text {
+		if !isSafeChar(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeChar(ch rune) bool {
+	return (ch >= 'a' && ch <= 'z') ||
+		(ch >= 'A' && ch <= 'Z') ||
+		(ch >= '0' && ch <= '9') ||
+		ch == '-' || ch == '.' || ch == '_' || ch == '/' || ch == '@' || ch == '^' || ch == '+'
+}
+
+func percentEncode(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if c := text[i]; c < utf8.RuneSelf && isSafeChar(rune(c)) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (f *TextFormatter) dereference(value interface{}) interface{} {
+	for depth := 0; depth < maxDereferenceDepth; depth++ {
+		switch value.(type) {
+		case error, fmt.Stringer:
+			return value
+		}
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Ptr {
+			return value
+		}
+		if v.IsNil() {
+			if f.NilPointerText != "" {
+				return f.NilPointerText
+			}
+			return "<nil>"
+		}
+		value = v.Elem().Interface()
+	}
+	return value
+}
+
+func (f *TextFormatter) appendValue(b *bytes.Buffer, value interface{}) {
+	if complexVal, ok := f.formatComplexValue(value); ok {
+		b.WriteString(complexVal)
+		return
+	}
+
+	stringVal, ok := value.(string)
+	if !ok {
+		stringVal = f.formatNonString(value)
+	} else if f.RenderColorSwatches && f.isTrueColor && f.isColored() {
+		if r, g, bl, ok := hexColor(stringVal); ok {
+			fmt.Fprintf(b, "\x1b[48;2;%d;%d;%dm  \x1b[0m ", r, g, bl)
+		}
+	}
+
+	if f.PercentEncodeValues {
+		b.WriteString(percentEncode(stringVal))
+	} else if !f.needsQuoting(stringVal) {
+		b.WriteString(stringVal)
+	} else {
+		b.WriteString(f.quote(stringVal))
+	}
+}
+
+func (f *TextFormatter) formatNonString(value interface{}) string {
+	if text, ok := f.nonFiniteText(value); ok {
+		return text
+	}
+	if v, ok := value.(bool); ok && f.BoolAsInt {
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	if f.FloatPrecision > 0 {
+		switch v := value.(type) {
+		case float32:
+			return strconv.FormatFloat(float64(v), 'f', f.FloatPrecision, 32)
+		case float64:
+			return strconv.FormatFloat(v, 'f', f.FloatPrecision, 64)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+func (f *TextFormatter) nonFiniteText(value interface{}) (string, bool) {
+	var v float64
+	switch n := value.(type) {
+	case float32:
+		v = float64(n)
+	case float64:
+		v = n
+	default:
+		return "", false
+	}
+
+	switch {
+	case math.IsNaN(v) && f.NaNText != "":
+		return f.NaNText, true
+	case math.IsInf(v, 1) && f.InfText != "":
+		return f.InfText, true
+	case math.IsInf(v, -1) && f.InfText != "":
+		return "-" + f.InfText, true
+	}
+	return "", false
+}
+
+func (f *TextFormatter) maxValueLength(key string) int {
+	if limit, ok := f.FieldMaxValueLengths[key]; ok {
+		return limit
+	}
+	return f.MaxFieldValueLength
+}
+
+func (f *TextFormatter) renderValue(key string, value interface{}) string {
+	if f.DereferencePointers {
+		value = f.dereference(value)
+	}
+	if f.isByteField(key) {
+		if size, ok := byteCount(value); ok {
+			value = humanizeBytes(size, f.SIByteUnits)
+		}
+	}
+	if containsKey(f.ShortenURLFields, key) {
+		value = f.shortenURL(value)
+	}
+	if containsKey(f.SparklineFields, key) {
+		if line, ok := sparkline(value); ok {
+			return line
+		}
+	}
+	if text, ok := value.(string); ok && f.CompactValueWhitespace {
+		value = strings.Join(strings.Fields(text), " ")
+	}
+	if text, ok := value.(string); ok && f.RedactSecretValues && f.isSecret(text) {
+		value = f.redactReplacement()
+	}
+	if limit := f.maxValueLength(key); limit > 0 && (f.ComplexValueStyle == ComplexValueGo || !isComplexValue(value)) {
+		text, ok := value.(string)
+		if !ok {
+			text = f.formatNonString(value)
+		}
+		if f.MiddleTruncate {
+			value = truncateMiddle(text, limit)
+		} else {
+			value = clampWidth(text, limit)
+		}
+	}
+
+	var b bytes.Buffer
+	f.appendValue(&b, value)
+	return b.String()
+}
+
+func (f *TextFormatter) isQuoted(rendered string) bool {
+	quoteChar := f.QuoteChar
+	if quoteChar == 0 {
+		quoteChar = '"'
+	}
+	r, _ := utf8.DecodeRuneInString(rendered)
+	return r == quoteChar
+}
+
+func (f *TextFormatter) quote(text string) string {
+	if f.QuoteChar == 0 || f.QuoteChar == '"' {
+		return fmt.Sprintf("%q", text)
+	}
+
+	// Escape the way %q does, but around QuoteChar instead of '"'.
+	var b strings.Builder
+	b.WriteRune(f.QuoteChar)
+	for i := 0; i < len(text); {
+		ch, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case ch == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, text[i])
+		case ch == f.QuoteChar || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(ch)
+		case strconv.IsPrint(ch):
+			b.WriteRune(ch)
+		default:
+			quoted := strconv.QuoteRune(ch)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		}
+		i += size
+	}
+	b.WriteRune(f.QuoteChar)
+	return b.String()
+}
+
+func (f *TextFormatter) callerText(entry *logrus.Entry) string {
+	if f.CallerChainDepth > 0 {
+		if chain := f.callerChain(); chain != "" {
+			return chain
+		}
+	}
+	if !entry.HasCaller() {
+		return ""
+	}
+
+	function := entry.Caller.Function
+	if !f.DisableGenericParamStripping {
+		function = stripGenericParams(function)
+	}
+
+	var (
+		funcVal = fmt.Sprintf("%s:%d", function, entry.Caller.Line)
+		fileVal string
+	)
+
+	if f.CallerPrettyfier != nil {
+		funcVal, fileVal = f.CallerPrettyfier(entry.Caller)
+		if f.NormalizeCallerSeparators {
+			fileVal = strings.Replace(fileVal, `\`, "/", -1)
+		}
+	} else if f.CallerFuncOnly {
+		funcVal = bareFunctionName(function)
+	} else if f.AbbreviateCallerPath || f.CallerPathSegments > 0 {
+		file := entry.Caller.File
+		if f.NormalizeCallerSeparators {
+			file = strings.Replace(file, `\`, "/", -1)
+		}
+		if f.CallerPathSegments > 0 {
+			file = lastPathSegments(file, f.CallerPathSegments)
+		}
+		if f.AbbreviateCallerPath {
+			file = abbreviatePath(file)
+		}
+		funcVal = ""
+		fileVal = fmt.Sprintf("%s:%d", file, entry.Caller.Line)
+	}
+
+	if fileVal == "" {
+		return funcVal
+	} else if funcVal == "" {
+		return fileVal
+	}
+	return fileVal + " " + funcVal
+}
+
+func (f *TextFormatter) callerChain() string {
+	pcs := make([]uintptr, maximumCallerDepth)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	arrow := f.CallerChainArrow
+	if arrow == "" {
+		arrow = "←"
+	}
+
+	chain := make([]string, 0, f.CallerChainDepth)
+	for len(chain) < f.CallerChainDepth {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, logrusPackage) && !strings.HasPrefix(frame.Function, formatterPackage+".") {
+			chain = append(chain, fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return strings.Join(chain, arrow)
+}
+
+func (f *TextFormatter) continuation(lines string) string {
+	prefix := f.ContinuationPrefix
+	if prefix == "" {
+		prefix = defaultContinuationPrefix
+	}
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(lines, "\n") {
+		if line != "" {
+			b.WriteString(prefix + line)
+		}
+	}
+	return b.String()
+}
+
+func eventTimestamp(value interface{}, layout string) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, layout} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// applyMessageCase normalizes the case of the message template. With
+// InterpolateMessage the placeholders are left alone, so neither their
+// names nor the values later substituted for them change case.
+func (f *TextFormatter) applyMessageCase(message string) string {
+	if f.MessageCase == MessageCaseAsIs {
+		return message
+	}
+	if !f.InterpolateMessage {
+		return f.caseText(message, true)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range placeholderPattern.FindAllStringIndex(message, -1) {
+		b.WriteString(f.caseText(message[last:match[0]], last == 0))
+		b.WriteString(message[match[0]:match[1]])
+		last = match[1]
+	}
+	b.WriteString(f.caseText(message[last:], last == 0))
+	return b.String()
+}
+
+func (f *TextFormatter) caseText(text string, atStart bool) string {
+	switch f.MessageCase {
+	case MessageCaseLower:
+		return strings.ToLower(text)
+	case MessageCaseUpper:
+		return strings.ToUpper(text)
+	case MessageCaseSentence:
+		if text == "" || !atStart {
+			return text
+		}
+		first, size := utf8.DecodeRuneInString(text)
+		return string(unicode.ToUpper(first)) + text[size:]
+	}
+	return text
+}
+
+func (f *TextFormatter) messageIcon(message string) string {
+	if len(f.MessageIcons) == 0 {
+		return ""
+	}
+
+	patterns := make([]string, 0, len(f.MessageIcons))
+	for pattern := range f.MessageIcons {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		if strings.HasPrefix(message, pattern) {
+			return f.MessageIcons[pattern]
+		}
+	}
+	for _, pattern := range patterns {
+		if strings.Contains(message, pattern) {
+			return f.MessageIcons[pattern]
+		}
+	}
+	return ""
+}
+
+func (f *TextFormatter) keyValueSeparator() string {
+	separator := f.KeyValueSeparator
+	if separator == "" {
+		separator = "="
+	}
+	if f.SpaceAroundEquals {
+		separator = " " + separator + " "
+	}
+	return separator
+}
+
+func (f *TextFormatter) fieldPrefix(index int) string {
+	if !f.CompactFields || index == 0 || f.FieldSeparator == "" {
+		return " "
+	}
+	return f.FieldSeparator
+}
+
+func (f *TextFormatter) finish(formatted []byte) []byte {
+	if f.PostProcess != nil {
+		formatted = f.PostProcess(formatted)
+	}
+	if f.ringBuffer != nil {
+		f.ringBuffer.add(formatted)
+	}
+	return formatted
+}
+
+func (f *TextFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if f.TeeWriter != nil {
+		f.tee(entry)
+	}
+
+	overrideKey := f.OverrideFieldKey
+	var override FormatOverride
+	if overrideKey != "" {
+		override = parseFormatOverride(entry.Data[overrideKey])
+	}
+
+	data := make(logrus.Fields)
+	for k, v := range entry.Data {
+		if overrideKey != "" && k == overrideKey {
+			continue
+		}
+		if f.OmitZeroValueFields && f.isZeroValue(k, v) {
+			continue
+		}
+		data[k] = v
+	}
+
+	var timestampValue interface{}
+	if f.TimestampField != "" {
+		timestampValue = data[f.TimestampField]
+		delete(data, f.TimestampField)
+	}
+
+	var (
+		promotedError    interface{}
+		hasPromotedError bool
+	)
+	if f.PromoteErrorField {
+		errorKey := f.ErrorFieldKey
+		if errorKey == "" {
+			errorKey = logrus.ErrorKey
+		}
+		if v, ok := data[errorKey]; ok {
+			promotedError, hasPromotedError = v, true
+			delete(data, errorKey)
+		}
+	}
+
+	var b *bytes.Buffer
+	if entry.Buffer != nil {
+		b = entry.Buffer
+	} else {
+		b = &bytes.Buffer{}
+	}
+
+	f.terminalInitOnce.Do(func() { f.init(entry) })
+
+	if override.Raw {
+		b.WriteString(strings.TrimSuffix(entry.Message, "\n"))
+		b.WriteByte('\n')
+		return f.finish(b.Bytes()), nil
+	}
+
+	colored := f.isColored() && !override.NoColor
+	disableTimestamp := f.DisableTimestamp || override.NoTimestamp
+
+	emptyEntry := len(data) == 0 && !hasPromotedError && strings.TrimSpace(entry.Message) == ""
+	if emptyEntry && f.EmptyEntryBehavior == EmptyEntrySkip {
+		return nil, nil
+	}
+
+	if f.OutputCLF {
+		if line, ok := f.formatCLF(entry); ok {
+			b.WriteString(line)
+			b.WriteByte('\n')
+			return f.finish(b.Bytes()), nil
+		}
+	}
+
+	messageText := f.applyMessageCase(strings.TrimSuffix(entry.Message, "\n"))
+	if f.InterpolateMessage {
+		var interpolated []string
+		messageText, interpolated = f.interpolate(messageText, entry.Data, overrideKey)
+		if f.RemoveInterpolatedFields {
+			for _, key := range interpolated {
+				delete(data, key)
+			}
+		}
+	}
+
+	summary := ""
+	if v, ok := data[f.MessageFieldSummary]; ok && f.MessageFieldSummary != "" {
+		summary = " (" + f.MessageFieldSummary + f.keyValueSeparator() + f.renderValue(f.MessageFieldSummary, v) + ")"
+	}
+
+	var tree strings.Builder
+	for _, k := range f.TreeFields {
+		if v, ok := data[k]; ok && isTreeValue(v) {
+			tree.WriteString(k + ":")
+			writeTree(&tree, reflect.ValueOf(v), 1)
+			delete(data, k)
+		}
+	}
+
+	keys := make([]string, 0, len(data))
+	values := make(map[string]string, len(data))
+	displayed := make(logrus.Fields, len(data))
+	originalKey := make(map[string]string, len(data))
+	original := make([]string, 0, len(data))
+	for k := range data {
+		original = append(original, k)
+	}
+	sort.Strings(original)
+	displayKey := make(map[string]string, len(original))
+	for _, k := range original {
+		key := k
+		if abbreviation, ok := f.KeyAbbreviations[k]; ok {
+			key = abbreviation
+		}
+		if f.MaxKeyLength > 0 {
+			key = clampWidth(key, f.MaxKeyLength)
+		}
+		displayKey[k] = key
+	}
+	// Keys that keep their own name claim it first, so a collision always
+	// suffixes the abbreviated or clamped key rather than the untouched one.
+	sort.SliceStable(original, func(i, j int) bool {
+		return displayKey[original[i]] == original[i] && displayKey[original[j]] != original[j]
+	})
+	for _, k := range original {
+		v := data[k]
+		key := displayKey[k]
+		if _, taken := displayed[key]; taken {
+			for n := 2; ; n++ {
+				if _, taken := displayed[key+strconv.Itoa(n)]; !taken {
+					key += strconv.Itoa(n)
+					break
+				}
+			}
+		}
+		keys = append(keys, key)
+		values[key] = f.renderValue(k, v)
+		displayed[key] = v
+		originalKey[key] = k
+	}
+	data = displayed
+
+	if !f.DisableSorting {
+		switch {
+		case f.SortByValueLength:
+			sort.Slice(keys, func(i, j int) bool {
+				li, lj := utf8.RuneCountInString(values[keys[i]]), utf8.RuneCountInString(values[keys[j]])
+				if li != lj {
+					return li < lj
+				}
+				return keys[i] < keys[j]
+			})
+		case f.SortingFunc == nil:
+			sort.Strings(keys)
+		default:
+			f.SortingFunc(keys)
+		}
+	}
+
+	timestampFormat := f.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = defaultTimestampFormat
+		if f.TimestampCompact {
+			timestampFormat = compactTimestampFormat
+		}
+	}
+
+	eventTime := entry.Time
+	if t, ok := eventTimestamp(timestampValue, timestampFormat); ok {
+		eventTime = t
+	}
+	timestamp := eventTime.Format(timestampFormat)
+	switch f.TimestampEpoch {
+	case EpochSeconds:
+		timestamp = strconv.FormatInt(eventTime.Unix(), 10)
+	case EpochMillis:
+		timestamp = strconv.FormatInt(eventTime.UnixNano()/int64(time.Millisecond), 10)
+	case EpochNanos:
+		timestamp = strconv.FormatInt(eventTime.UnixNano(), 10)
+	}
+	if f.SecondaryTimezone != nil {
+		secondaryFormat := f.SecondaryTimestampFormat
+		if secondaryFormat == "" {
+			secondaryFormat = timestampFormat
+		}
+		timestamp += " (" + eventTime.In(f.SecondaryTimezone).Format(secondaryFormat) + ")"
+	}
+
+	if disableTimestamp && f.TimestampPlaceholder != "" {
+		timestamp = padRight(f.TimestampPlaceholder, visibleLength(timestamp))
+		disableTimestamp = false
+	}
+
+	levelColor := -1
+	separator := " :: "
+
+	if colored {
+		levelColor = f.colorForLevel(entry.Level)
+		if messageColor, ok := f.MessageLevelColorMap[strings.TrimSuffix(messageText, "\n")]; ok {
+			levelColor = messageColor
+		}
+
+		timestamp = f.colorPrint(timestamp, faint)
+		separator = " "
+	}
+	if f.ColumnDivider != "" {
+		dividerColor := -1
+		if colored {
+			dividerColor = faint
+		}
+		separator = " " + f.colorPrint(f.ColumnDivider, dividerColor) + " "
+	}
+	if f.ColumnSpacing > 0 {
+		spacing := strings.Repeat(" ", f.ColumnSpacing)
+		if divider := strings.TrimSpace(separator); divider != "" {
+			separator = spacing + divider + spacing
+		} else {
+			separator = spacing
+		}
+	}
+
+	levelText := strings.ToUpper(entry.Level.String())
+
+	if f.TruncateLevelText && !f.PadLevelText {
+		levelText = levelText[0:4]
+	}
+	if f.PadLevelText {
+		formatString := "%-" + strconv.Itoa(f.levelTextMaxLength) + "s"
+		levelText = fmt.Sprintf(formatString, levelText)
+	}
+	if f.LevelMaxWidth > 0 {
+		levelText = clampWidth(strings.TrimRight(levelText, " "), f.LevelMaxWidth)
+		if f.PadLevelText {
+			levelText = padRight(levelText, f.LevelMaxWidth)
+		}
+	}
+	if f.LevelMinWidth > 0 {
+		levelText = padRight(levelText, f.LevelMinWidth)
+	}
+
+	messageText = strings.TrimSuffix(messageText, "\n")
+	messageText += summary
+	if f.ContinuationPrefix != "" {
+		messageText = strings.Replace(messageText, "\n", "\n"+f.ContinuationPrefix, -1)
+	}
+
+	// Every physical line carries the PRI so syslog can parse it.
+	pri := ""
+	if f.OutputSyslog {
+		pri = fmt.Sprintf("<%d>", f.syslogFacility()*8+syslogSeverity(entry.Level))
+	}
+
+	if f.SeparateLevelChanges && f.levelChanged(entry.Level) {
+		b.WriteString(pri)
+		separatorColor := -1
+		if colored {
+			separatorColor = faint
+		}
+		b.WriteString(f.colorPrint(strings.Repeat("─", levelSeparatorWidth), separatorColor))
+		b.WriteByte('\n')
+	}
+	if colored && f.EmitColorLegend {
+		f.legendOnce.Do(func() {
+			b.WriteString(pri)
+			b.WriteString(f.colorLegend())
+			b.WriteByte('\n')
+		})
+	}
+
+	lineStart := b.Len()
+
+	caller := ""
+	if !override.NoCaller {
+		caller = f.callerText(entry)
+	}
+	if caller != "" && f.ElideRepeatedCaller && f.callerRepeated(caller) {
+		caller = f.RepeatedCallerMarker
+		if caller == "" {
+			caller = "↑"
+		}
+	}
+	if caller != "" {
+		if f.CallerWidth > 0 {
+			caller = truncateHead(caller, f.CallerWidth)
+		}
+		caller = " (" + caller + ")"
+	}
+	if f.CallerWidth > 0 {
+		caller = padRight(caller, f.CallerWidth+3)
+	}
+
+	b.WriteString(pri)
+
+	if f.SeverityGutter {
+		b.WriteString(f.colorPrint(f.gutterSymbol(entry.Level), levelColor))
+	}
+
+	if f.VerboseFirstLine {
+		f.firstLineOnce.Do(func() {
+			anchorColor := -1
+			if colored {
+				anchorColor = faint
+			}
+			b.WriteString(f.colorPrint(firstLineAnchor(eventTime), anchorColor))
+			b.WriteByte(' ')
+		})
+	}
+
+	colorSection := f.colorPrint(fmt.Sprintf("%s%s", levelText, caller), levelColor)
+	if colored {
+		var attributes []string
+		if f.UnderlineLevels[entry.Level] {
+			attributes = append(attributes, "4")
+		}
+		if f.BlinkLevels[entry.Level] {
+			attributes = append(attributes, "5")
+		}
+		if len(attributes) > 0 {
+			if color := f.paletteColor(levelColor); color > 0 {
+				attributes = append(attributes, strconv.Itoa(color))
+			}
+			colorSection = fmt.Sprintf("\x1b[%sm%s\x1b[24;25m%s%s", strings.Join(attributes, ";"), levelText, caller, colorReset)
+		}
+	}
+
+	if f.ShowPID || f.ShowTID {
+		idColor := -1
+		if colored {
+			idColor = faint
+		}
+		colorSection = f.colorPrint("["+f.processIDs()+"]", idColor) + " " + colorSection
+	}
+
+	var changed map[string]bool
+	if f.MarkChangedValues {
+		changed = f.changedValues(values)
+	}
+
+	fieldColor := levelColor
+	switch f.FieldColorInherits {
+	case FieldColorNone:
+		fieldColor = -1
+	case FieldColorFromMessage:
+		if f.ColorExtent != ColorExtentThroughMessage {
+			fieldColor = -1
+		}
+	}
+	formatField := func(k string, pad bool) string {
+		name := originalKey[k]
+		if v, ok := data[k].(bool); ok && f.CheckmarkBools && colored {
+			mark := f.colorPrint("✗", red)
+			if v {
+				mark = f.colorPrint("✓", green)
+			}
+			return fmt.Sprintf("%s %s", f.colorPrint(f.FieldKeyPrefix+k, fieldColor), mark)
+		}
+		value := values[k]
+		if f.PadShortValues > 0 && pad && !f.isQuoted(value) {
+			value = padRight(value, f.PadShortValues)
+		}
+		keyColor, valueColor := fieldColor, -1
+		if colored {
+			if typeColor, ok := f.TypeColors[reflect.ValueOf(data[k]).Kind()]; ok {
+				valueColor = typeColor
+			}
+			if v, ok := data[k].(bool); ok {
+				if boolColor := f.boolColor(v); boolColor > 0 {
+					valueColor = boolColor
+				}
+			}
+			if style, ok := f.FieldStyles[name]; ok {
+				if style.KeyColor > 0 {
+					keyColor = style.KeyColor
+				}
+				if style.ValueColor > 0 {
+					valueColor = style.ValueColor
+				}
+			}
+			if v, ok := data[k].(string); ok {
+				if enumColor, ok := f.ValueColorMap[v]; ok {
+					valueColor = enumColor
+				}
+			}
+			if ipColor := f.ipColor(name, data[k]); ipColor > 0 {
+				valueColor = ipColor
+			}
+			if name == logrus.FieldKeyLogrusError {
+				keyColor, valueColor = yellow, yellow
+			}
+		}
+		value = f.colorPrint(value, valueColor)
+		if changed[k] {
+			markColor := -1
+			if colored {
+				markColor = faint
+			}
+			value = f.colorPrint("*", markColor) + value
+		}
+		return f.colorPrint(f.FieldKeyPrefix+k, keyColor) + f.keyValueSeparator() + value
+	}
+
+	message := messageText
+	if emptyEntry && f.EmptyEntryBehavior == EmptyEntryMarker {
+		markerColor := -1
+		if colored {
+			markerColor = faint
+		}
+		message = f.colorPrint(emptyEntryMarker, markerColor)
+	}
+	if icon := f.messageIcon(messageText); icon != "" {
+		message = f.colorPrint(icon, levelColor) + " " + message
+	}
+	trailing := keys
+	if len(f.InlineFields) > 0 {
+		trailing = make([]string, 0, len(keys))
+		for _, k := range keys {
+			if containsKey(f.InlineFields, originalKey[k]) {
+				message += " " + formatField(k, false)
+			} else {
+				trailing = append(trailing, k)
+			}
+		}
+	}
+	if hasPromotedError {
+		errorColor := -1
+		if colored {
+			errorColor = red
+		}
+		message += " " + f.colorPrint("← "+fmt.Sprint(promotedError), errorColor)
+	}
+	if indent := f.LevelIndent[entry.Level]; indent > 0 {
+		message = strings.Repeat(" ", indent) + message
+	}
+	if !f.CompactFields {
+		message = padRight(message, 44) + " "
+	}
+
+	messageSeparator, messageEnd := separator, ""
+	if colored && f.ColorExtent != ColorExtentLevelOnly && strings.HasSuffix(colorSection, colorReset) {
+		colorSection = strings.TrimSuffix(colorSection, colorReset)
+		if f.ColorExtent == ColorExtentThroughSeparator {
+			messageSeparator += colorReset
+		} else {
+			messageEnd = colorReset
+		}
+	}
+
+	switch {
+	case disableTimestamp:
+		fmt.Fprintf(b, "%s%s%s%s", colorSection, messageSeparator, message, messageEnd)
+	default:
+		fmt.Fprintf(b, "%s%s%s%s%s%s", timestamp, separator, colorSection, messageSeparator, message, messageEnd)
+	}
+	delimited := len(trailing) > 0 && (f.FieldBlockDelimiters[0] != "" || f.FieldBlockDelimiters[1] != "")
+	for i, k := range trailing {
+		prefix := f.fieldPrefix(i)
+		if i == 0 && f.MessageFieldSeparator != "" && messageText != "" {
+			prefix = f.MessageFieldSeparator
+		}
+		if i == 0 && delimited {
+			prefix += f.FieldBlockDelimiters[0]
+		}
+		b.WriteString(prefix)
+		b.WriteString(formatField(k, i < len(trailing)-1))
+	}
+	if delimited {
+		b.WriteString(f.FieldBlockDelimiters[1])
+	}
+
+	if len(keys) == 0 && f.NoFieldsIndicator != "" {
+		indicatorColor := -1
+		if colored {
+			indicatorColor = faint
+		}
+		fmt.Fprintf(b, " %s", f.colorPrint(f.NoFieldsIndicator, indicatorColor))
+	}
+
+	if f.Version != "" {
+		versionColor := -1
+		if colored {
+			versionColor = faint
+		}
+		fmt.Fprintf(b, " %s", f.colorPrint(f.Version, versionColor))
+	}
+
+	if f.ShowLogRate {
+		rateColor := -1
+		if colored {
+			rateColor = faint
+		}
+		rate := fmt.Sprintf("(%d/s)", f.logRate(entry.Time))
+		fmt.Fprintf(b, " %s", f.colorPrint(rate, rateColor))
+	}
+
+	if f.ShowContextIndicator && entry.Context != nil {
+		contextColor := -1
+		if colored {
+			contextColor = faint
+		}
+		fmt.Fprintf(b, " %s", f.colorPrint(contextIndicator, contextColor))
+	}
+
+	if f.MaxLineBytes > 0 && b.Len()-lineStart > f.MaxLineBytes {
+		line := truncateLine(b.Bytes()[lineStart:], f.MaxLineBytes)
+		b.Truncate(lineStart)
+		b.Write(line)
+	}
+
+	b.WriteByte('\n')
+	b.WriteString(f.continuation(tree.String()))
+
+	if override.NoColor {
+		stripped := escapePattern.ReplaceAll(b.Bytes(), nil)
+		b.Reset()
+		b.Write(stripped)
+	}
+	return f.finish(b.Bytes()), nil
 }
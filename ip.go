@@ -0,0 +1,70 @@
+package formatter
+
+import (
+	"net"
+)
+
+var privateNetworks = parseNetworks(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// ipColor returns the color for value when key is listed in ColorIPFields
+// and value parses as an IP address, or -1 otherwise. Unset colors fall
+// back to gray for loopback, green for private and yellow for public
+// addresses.
+func (f *TextFormatter) ipColor(key string, value interface{}) int {
+	if !containsKey(f.ColorIPFields, key) {
+		return -1
+	}
+	var ip net.IP
+	switch v := value.(type) {
+	case net.IP:
+		ip = v
+	case string:
+		ip = net.ParseIP(v)
+	}
+	if ip == nil {
+		return -1
+	}
+	switch {
+	case ip.IsLoopback():
+		return colorOrDefault(f.LoopbackIPColor, gray)
+	case isPrivateIP(ip):
+		return colorOrDefault(f.PrivateIPColor, green)
+	default:
+		return colorOrDefault(f.PublicIPColor, yellow)
+	}
+}
+
+func isPrivateIP(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func colorOrDefault(color, fallback int) int {
+	if color > 0 {
+		return color
+	}
+	return fallback
+}
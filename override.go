@@ -0,0 +1,42 @@
+package formatter
+
+import (
+	"regexp"
+	"strings"
+)
+
+var escapePattern = regexp.MustCompile("\x1b\\[48;2;[0-9;]*m  \x1b\\[0m |\x1b\\[[0-9;]*m")
+
+type FormatOverride struct {
+	Raw         bool
+	NoColor     bool
+	NoTimestamp bool
+	NoCaller    bool
+}
+
+func parseFormatOverride(value interface{}) FormatOverride {
+	switch v := value.(type) {
+	case FormatOverride:
+		return v
+	case *FormatOverride:
+		if v != nil {
+			return *v
+		}
+	case string:
+		var o FormatOverride
+		for _, flag := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }) {
+			switch strings.ToLower(flag) {
+			case "raw":
+				o.Raw = true
+			case "nocolor":
+				o.NoColor = true
+			case "notimestamp":
+				o.NoTimestamp = true
+			case "nocaller":
+				o.NoCaller = true
+			}
+		}
+		return o
+	}
+	return FormatOverride{}
+}
@@ -0,0 +1,53 @@
+package formatter
+
+import (
+	"strings"
+	"sync"
+)
+
+type RingBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func NewRingBuffer(capacity int) *RingBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingBuffer{lines: make([]string, capacity)}
+}
+
+// add stores each physical line of formatted separately, so separator,
+// legend, tree and continuation lines take their own slots.
+func (r *RingBuffer) add(formatted []byte) {
+	lines := strings.Split(strings.TrimSuffix(string(formatted), "\n"), "\n")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, line := range lines {
+		r.lines[r.next] = line
+		r.next = (r.next + 1) % len(r.lines)
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+}
+
+func (r *RingBuffer) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]string(nil), r.lines[:r.next]...)
+	}
+	lines := make([]string, 0, len(r.lines))
+	lines = append(lines, r.lines[r.next:]...)
+	return append(lines, r.lines[:r.next]...)
+}
+
+func (f *TextFormatter) AttachRingBuffer(r *RingBuffer) {
+	f.ringBuffer = r
+}
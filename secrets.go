@@ -0,0 +1,60 @@
+package formatter
+
+import (
+	"math"
+	"regexp"
+)
+
+const (
+	defaultRedactReplacement = "[REDACTED]"
+
+	minSecretEntropyLength = 20
+)
+
+var secretCharsetPattern = regexp.MustCompile(`^[A-Za-z0-9+/=_-]+$`)
+
+var defaultSecretDetectors = []*regexp.Regexp{
+	regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`),
+	regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36}\b`),
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`),
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
+}
+
+func (f *TextFormatter) isSecret(text string) bool {
+	detectors := f.SecretDetectors
+	if detectors == nil {
+		detectors = defaultSecretDetectors
+	}
+	for _, detector := range detectors {
+		if detector.MatchString(text) {
+			return true
+		}
+	}
+
+	return f.SecretEntropyBits > 0 && len(text) >= minSecretEntropyLength &&
+		secretCharsetPattern.MatchString(text) && shannonEntropy(text) >= f.SecretEntropyBits
+}
+
+func (f *TextFormatter) redactReplacement() string {
+	if f.RedactReplacement == "" {
+		return defaultRedactReplacement
+	}
+	return f.RedactReplacement
+}
+
+func shannonEntropy(text string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, ch := range text {
+		counts[ch]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
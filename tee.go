@@ -0,0 +1,81 @@
+package formatter
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+type TeeFormat int
+
+const (
+	TeeJSON TeeFormat = iota
+	TeeLogfmt
+)
+
+func (f *TextFormatter) tee(entry *logrus.Entry) {
+	var structured logrus.Formatter
+	switch f.TeeFormat {
+	case TeeLogfmt:
+		structured = &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+	default:
+		structured = &logrus.JSONFormatter{}
+	}
+
+	teeEntry := *entry
+	teeEntry.Buffer = nil
+	teeEntry.Data = f.teeFields(entry.Data)
+
+	serialized, err := structured.Format(&teeEntry)
+	if err == nil {
+		f.teeMu.Lock()
+		_, err = f.TeeWriter.Write(serialized)
+		f.teeMu.Unlock()
+	}
+	if err != nil && f.ReportTeeErrors {
+		fmt.Fprintf(os.Stderr, "Failed to write to tee writer, %v\n", err)
+	}
+}
+
+// teeFields returns data with non-finite floats spelled out and, when
+// RedactSecretValues is set, secrets replaced, copying it only if needed.
+func (f *TextFormatter) teeFields(data logrus.Fields) logrus.Fields {
+	var fields logrus.Fields
+	for k, v := range data {
+		var replacement interface{}
+		if isNonFinite(v) {
+			if text, ok := f.nonFiniteText(v); ok {
+				replacement = text
+			} else {
+				replacement = fmt.Sprint(v)
+			}
+		} else if text, ok := v.(string); ok && f.RedactSecretValues && f.isSecret(text) {
+			replacement = f.redactReplacement()
+		} else {
+			continue
+		}
+		if fields == nil {
+			fields = make(logrus.Fields, len(data))
+			for k, v := range data {
+				fields[k] = v
+			}
+		}
+		fields[k] = replacement
+	}
+	if fields == nil {
+		return data
+	}
+	return fields
+}
+
+func isNonFinite(value interface{}) bool {
+	switch v := value.(type) {
+	case float32:
+		return math.IsNaN(float64(v)) || math.IsInf(float64(v), 0)
+	case float64:
+		return math.IsNaN(v) || math.IsInf(v, 0)
+	}
+	return false
+}
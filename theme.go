@@ -0,0 +1,106 @@
+package formatter
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Theme bundles the styling options so they can be loaded from JSON and
+// applied in one call.
+type Theme struct {
+	LevelColors map[string]int        `json:"level_colors,omitempty"`
+	FieldStyles map[string]FieldStyle `json:"field_styles,omitempty"`
+
+	ColumnDivider     string `json:"column_divider,omitempty"`
+	FieldSeparator    string `json:"field_separator,omitempty"`
+	KeyValueSeparator string `json:"key_value_separator,omitempty"`
+
+	PadLevelText  bool `json:"pad_level_text,omitempty"`
+	LevelMinWidth int  `json:"level_min_width,omitempty"`
+	LevelMaxWidth int  `json:"level_max_width,omitempty"`
+
+	TimestampFormat string `json:"timestamp_format,omitempty"`
+}
+
+// ApplyTheme validates t and merges it into the formatter: only the
+// settings t sets (non-empty strings, non-zero widths, true flags and the
+// entries of its maps) are applied, so themes can be layered and a theme
+// never clears an existing setting. Nothing is changed when an error is
+// returned.
+func (f *TextFormatter) ApplyTheme(t Theme) error {
+	levelColors := make(map[logrus.Level]int, len(t.LevelColors))
+	for name, color := range t.LevelColors {
+		level, err := logrus.ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("theme: %v", err)
+		}
+		if !isValidColor(color) {
+			return fmt.Errorf("theme: invalid color %d for level %q", color, name)
+		}
+		levelColors[level] = color
+	}
+	for key, style := range t.FieldStyles {
+		if !isValidColor(style.KeyColor) || !isValidColor(style.ValueColor) {
+			return fmt.Errorf("theme: invalid color in style for field %q", key)
+		}
+	}
+	if t.LevelMinWidth < 0 || t.LevelMaxWidth < 0 {
+		return fmt.Errorf("theme: level widths must not be negative")
+	}
+	minWidth, maxWidth := f.LevelMinWidth, f.LevelMaxWidth
+	if t.LevelMinWidth > 0 {
+		minWidth = t.LevelMinWidth
+	}
+	if t.LevelMaxWidth > 0 {
+		maxWidth = t.LevelMaxWidth
+	}
+	if maxWidth > 0 && minWidth > maxWidth {
+		return fmt.Errorf("theme: level min width %d exceeds max width %d", minWidth, maxWidth)
+	}
+	if t.FieldSeparator != "" && !f.CompactFields {
+		return fmt.Errorf("theme: field separator requires CompactFields")
+	}
+
+	if len(levelColors) > 0 {
+		merged := make(map[logrus.Level]int, len(f.LevelColors)+len(levelColors))
+		for level, color := range f.LevelColors {
+			merged[level] = color
+		}
+		for level, color := range levelColors {
+			merged[level] = color
+		}
+		f.LevelColors = merged
+	}
+	if len(t.FieldStyles) > 0 {
+		merged := make(map[string]FieldStyle, len(f.FieldStyles)+len(t.FieldStyles))
+		for key, style := range f.FieldStyles {
+			merged[key] = style
+		}
+		for key, style := range t.FieldStyles {
+			merged[key] = style
+		}
+		f.FieldStyles = merged
+	}
+	if t.ColumnDivider != "" {
+		f.ColumnDivider = t.ColumnDivider
+	}
+	if t.FieldSeparator != "" {
+		f.FieldSeparator = t.FieldSeparator
+	}
+	if t.KeyValueSeparator != "" {
+		f.KeyValueSeparator = t.KeyValueSeparator
+	}
+	if t.PadLevelText {
+		f.PadLevelText = true
+	}
+	f.LevelMinWidth, f.LevelMaxWidth = minWidth, maxWidth
+	if t.TimestampFormat != "" {
+		f.TimestampFormat = t.TimestampFormat
+	}
+	return nil
+}
+
+func isValidColor(color int) bool {
+	return color >= 0 && color <= 107
+}
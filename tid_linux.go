@@ -0,0 +1,10 @@
+//go:build linux
+// +build linux
+
+package formatter
+
+import "syscall"
+
+func threadID() int {
+	return syscall.Gettid()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package formatter
+
+func threadID() int {
+	return goroutineID()
+}